@@ -0,0 +1,82 @@
+package gps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// Analyzer is a participant in the analyzer registry that contributes to
+// the inputs covered by HashInputs, alongside the project analyzer
+// already wired in through SourceManager. Each contributes its own name,
+// version, and optionally some project-derived bytes - build tag
+// configuration, vendor policy, license policy, and so on - so that a
+// change to any of those invalidates a memoized solve.
+type Analyzer interface {
+	Name() string
+	Version() int
+
+	// Contribute returns additional bytes to fold into HashInputs,
+	// derived from rm. An analyzer with nothing project-specific to
+	// contribute can return nil.
+	Contribute(rm RootManifest) ([]byte, error)
+}
+
+// AnalyzerSet is an ordered registry of Analyzers, all of which
+// participate in HashInputs, which writes the set held in the solver's
+// analyzers field. Analyzers are added through RegisterAnalyzer, so that
+// integrators - license checks, vulnerability scans,
+// build-tag-restricted resolution - can hook into solve memoization
+// without patching gps itself. SolveParams does not carry an
+// AnalyzerSet.
+type AnalyzerSet struct {
+	analyzers []Analyzer
+}
+
+// RegisterAnalyzer appends a to the set. Registration order doesn't
+// affect the resulting digest - writeTo sorts by Name() first - but it is
+// preserved for anything that iterates the set directly.
+func (as *AnalyzerSet) RegisterAnalyzer(a Analyzer) {
+	as.analyzers = append(as.analyzers, a)
+}
+
+// writeTo writes every registered analyzer's name, version, and
+// contributed bytes into buf, sorted by name so that registration order
+// has no bearing on the digest. The name and the contributed bytes are
+// each length-prefixed, and the version is written at a fixed width of 8
+// bytes, rather than simply concatenated, so that two different analyzer sets can never
+// produce the same bytes by having their field boundaries line up
+// differently (e.g. names "a" and "1b" back to back versus "a1" and
+// "b" alone).
+func (as *AnalyzerSet) writeTo(buf *bytes.Buffer, rm RootManifest) error {
+	sorted := make([]Analyzer, len(as.analyzers))
+	copy(sorted, as.analyzers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name() < sorted[j].Name()
+	})
+
+	for _, a := range sorted {
+		writeLenPrefixed(buf, []byte(a.Name()))
+
+		var verBuf [8]byte
+		binary.BigEndian.PutUint64(verBuf[:], uint64(a.Version()))
+		buf.Write(verBuf[:])
+
+		contrib, err := a.Contribute(rm)
+		if err != nil {
+			return err
+		}
+		writeLenPrefixed(buf, contrib)
+	}
+	return nil
+}
+
+// writeLenPrefixed writes b into buf preceded by its length, so that
+// adjacent variable-length fields can't be confused for one another by
+// whatever reads the buffer back as a flat byte stream.
+func writeLenPrefixed(buf *bytes.Buffer, b []byte) {
+	var lenBuf [8]byte
+	binary.BigEndian.PutUint64(lenBuf[:], uint64(len(b)))
+	buf.Write(lenBuf[:])
+	buf.Write(b)
+}
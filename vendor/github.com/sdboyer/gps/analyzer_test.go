@@ -0,0 +1,86 @@
+package gps
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakeAnalyzer struct {
+	name    string
+	version int
+	contrib []byte
+}
+
+func (a fakeAnalyzer) Name() string { return a.name }
+func (a fakeAnalyzer) Version() int { return a.version }
+func (a fakeAnalyzer) Contribute(RootManifest) ([]byte, error) {
+	return a.contrib, nil
+}
+
+func writeToBytes(t *testing.T, as *AnalyzerSet) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := as.writeTo(&buf, nil); err != nil {
+		t.Fatalf("writeTo: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestAnalyzerSet_WriteTo_NoFieldPrefixCollision regression-tests the bug
+// the unprefixed name+version concatenation had: name "a" with version 12
+// naively wrote the same bytes ("a" + "12") as name "a1" with version 2
+// ("a1" + "2"). writeTo must not collide on either of these.
+func TestAnalyzerSet_WriteTo_NoFieldPrefixCollision(t *testing.T) {
+	var setA, setB AnalyzerSet
+	setA.RegisterAnalyzer(fakeAnalyzer{name: "a", version: 12})
+	setB.RegisterAnalyzer(fakeAnalyzer{name: "a1", version: 2})
+
+	bufA := writeToBytes(t, &setA)
+	bufB := writeToBytes(t, &setB)
+
+	if bytes.Equal(bufA, bufB) {
+		t.Fatalf(`AnalyzerSet{"a",12} and AnalyzerSet{"a1",2} collided: both produced %x`, bufA)
+	}
+}
+
+// TestAnalyzerSet_WriteTo_VersionChangeFlipsDigest proves that bumping an
+// analyzer's Version() alone changes its contribution to the digest.
+func TestAnalyzerSet_WriteTo_VersionChangeFlipsDigest(t *testing.T) {
+	var v1, v2 AnalyzerSet
+	v1.RegisterAnalyzer(fakeAnalyzer{name: "license", version: 1, contrib: []byte("mit-only")})
+	v2.RegisterAnalyzer(fakeAnalyzer{name: "license", version: 2, contrib: []byte("mit-only")})
+
+	if bytes.Equal(writeToBytes(t, &v1), writeToBytes(t, &v2)) {
+		t.Fatal("changing an analyzer's version didn't change its contribution to the digest")
+	}
+}
+
+// TestAnalyzerSet_WriteTo_ContributeChangeFlipsDigest proves that a
+// change in the bytes an analyzer contributes - independent of its name
+// or version - changes the digest too.
+func TestAnalyzerSet_WriteTo_ContributeChangeFlipsDigest(t *testing.T) {
+	var before, after AnalyzerSet
+	before.RegisterAnalyzer(fakeAnalyzer{name: "license", version: 1, contrib: []byte("mit-only")})
+	after.RegisterAnalyzer(fakeAnalyzer{name: "license", version: 1, contrib: []byte("mit-and-gpl")})
+
+	if bytes.Equal(writeToBytes(t, &before), writeToBytes(t, &after)) {
+		t.Fatal("changing an analyzer's contributed bytes didn't change its contribution to the digest")
+	}
+}
+
+// TestAnalyzerSet_WriteTo_RegistrationOrderDoesNotMatter proves two sets
+// holding the same analyzers, registered in different orders, hash
+// identically - the whole point of sorting by Name() before writing.
+func TestAnalyzerSet_WriteTo_RegistrationOrderDoesNotMatter(t *testing.T) {
+	var forward, reverse AnalyzerSet
+	forward.RegisterAnalyzer(fakeAnalyzer{name: "a", version: 1})
+	forward.RegisterAnalyzer(fakeAnalyzer{name: "b", version: 1})
+
+	reverse.RegisterAnalyzer(fakeAnalyzer{name: "b", version: 1})
+	reverse.RegisterAnalyzer(fakeAnalyzer{name: "a", version: 1})
+
+	if !bytes.Equal(writeToBytes(t, &forward), writeToBytes(t, &reverse)) {
+		t.Fatal("registration order affected the digest, but writeTo is supposed to sort by Name() first")
+	}
+}
@@ -0,0 +1,305 @@
+package gps
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cache is a content-addressed store for Solve() results, keyed by the
+// digest returned from (*solver).HashInputs(). It lets repeated Solve()
+// calls - across CI runs, or successive invocations of the same tool -
+// skip the SAT search entirely when none of the inputs HashInputs covers
+// have changed.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get retrieves the Solution stored under digest, if any. The bool
+	// return reports whether an entry was found; its absence is not an
+	// error.
+	Get(digest []byte) (Solution, bool, error)
+
+	// Put stores s under digest, overwriting any entry already there.
+	Put(digest []byte, s Solution) error
+}
+
+// Memoize runs solve only if no cached Solution is already stored under
+// digest in cache; otherwise, it returns the cached Solution directly. A
+// freshly computed Solution is stored back into cache before being
+// returned. A nil cache disables memoization and always calls solve.
+//
+// solveWithCache calls Memoize with the Cache an integrator supplies,
+// s.HashInputs(), and s.Solve.
+func Memoize(cache Cache, digest []byte, solve func() (Solution, error)) (Solution, error) {
+	if cache == nil {
+		return solve()
+	}
+
+	if sol, has, err := cache.Get(digest); err == nil && has {
+		return sol, nil
+	}
+
+	sol, err := solve()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.Put(digest, sol); err != nil {
+		return nil, err
+	}
+	return sol, nil
+}
+
+// versionRecord is the gob-encodable projection of a Version. Version is
+// an interface over several unexported concrete types (branchVersion,
+// plainVersion, semVersion, Revision, and a PairedVersion wrapping any of
+// the former three with a Revision), none of which gob can see into - so
+// a Version can't be gob-encoded directly. encodeVersion/decodeVersion
+// take one apart into this projection and put it back together.
+type versionRecord struct {
+	Kind     byte
+	Name     string
+	Revision Revision
+}
+
+// encodeVersion reduces v to its gob-safe projection. It reuses the same
+// kind bytes writeConstraint hashes with, so nothing new needs inventing
+// to keep branch/tag/version/revision distinguishable.
+func encodeVersion(v Version) versionRecord {
+	switch tv := v.(type) {
+	case Revision:
+		return versionRecord{Kind: hashKindRevision, Revision: tv}
+	case branchVersion:
+		return versionRecord{Kind: hashKindBranch, Name: tv.name}
+	case plainVersion:
+		return versionRecord{Kind: hashKindTag, Name: string(tv)}
+	case semVersion:
+		return versionRecord{Kind: hashKindVersion, Name: tv.String()}
+	case PairedVersion:
+		rec := encodeVersion(tv.Unpair())
+		rec.Revision = tv.Underlying()
+		return rec
+	default:
+		// Only bare constraints (Any, a semver range) could reach here,
+		// and LockedProject.Version() never returns one of those - but
+		// fall back to the string form rather than panicking if it ever
+		// does.
+		return versionRecord{Kind: hashKindAny, Name: v.String()}
+	}
+}
+
+// decodeVersion reverses encodeVersion.
+func decodeVersion(rec versionRecord) Version {
+	var uv UnpairedVersion
+	switch rec.Kind {
+	case hashKindBranch:
+		uv = NewBranch(rec.Name)
+	case hashKindRevision:
+		return rec.Revision
+	case hashKindTag, hashKindVersion:
+		uv = NewVersion(rec.Name)
+	default:
+		// encodeVersion's own default case falls back to v.String() in
+		// Name for any Version kind it doesn't otherwise recognize -
+		// round-trip it the same way rather than discarding Name and
+		// returning a bare, empty Revision.
+		uv = NewVersion(rec.Name)
+	}
+	if rec.Revision != "" {
+		return uv.Pair(rec.Revision)
+	}
+	return uv
+}
+
+// lockedProjectRecord is the gob-encodable projection of a LockedProject
+// that solutionRecord actually persists. LockedProject, like Solution, is
+// an interface backed by an unexported type that carries its data in
+// unexported fields - gob would silently drop all of it, so
+// solutionRecord can't store []LockedProject directly without losing the
+// very data this cache exists to persist.
+type lockedProjectRecord struct {
+	Root        ProjectRoot
+	NetworkName string
+	Version     versionRecord
+	Packages    []string
+}
+
+func encodeLockedProject(lp LockedProject) lockedProjectRecord {
+	ident := lp.Ident()
+	return lockedProjectRecord{
+		Root:        ident.ProjectRoot,
+		NetworkName: ident.NetworkName,
+		Version:     encodeVersion(lp.Version()),
+		Packages:    lp.Packages(),
+	}
+}
+
+func (r lockedProjectRecord) decode() LockedProject {
+	return lockedProjectValue{
+		ident: ProjectIdentifier{
+			ProjectRoot: r.Root,
+			NetworkName: r.NetworkName,
+		},
+		version:  decodeVersion(r.Version),
+		packages: r.Packages,
+	}
+}
+
+// lockedProjectValue is a concrete LockedProject reconstructed from a
+// decoded lockedProjectRecord. It plays the same role for LockedProject
+// that solutionRecord plays for Solution.
+type lockedProjectValue struct {
+	ident    ProjectIdentifier
+	version  Version
+	packages []string
+}
+
+func (l lockedProjectValue) Ident() ProjectIdentifier { return l.ident }
+func (l lockedProjectValue) Version() Version         { return l.version }
+func (l lockedProjectValue) Packages() []string       { return l.packages }
+
+// solutionRecord is the gob-encodable snapshot of a Solution that a Cache
+// implementation actually persists. Solution is an interface, and its
+// concrete implementation is unexported, so it can't be gob-encoded
+// directly.
+type solutionRecord struct {
+	Digest          []byte
+	LockedProjects  []lockedProjectRecord
+	AttemptCount    int
+	AnalyzerName    string
+	AnalyzerVersion int
+}
+
+// analyzerInfoer is implemented by Solutions that can report the name and
+// version of the ProjectAnalyzer used to produce them. It's checked for
+// with a type assertion, since Solution itself doesn't require it.
+type analyzerInfoer interface {
+	AnalyzerInfo() (name string, version int)
+}
+
+func newSolutionRecord(digest []byte, s Solution) solutionRecord {
+	projects := s.Projects()
+	rec := solutionRecord{
+		Digest:         digest,
+		LockedProjects: make([]lockedProjectRecord, len(projects)),
+		AttemptCount:   s.Attempts(),
+	}
+	for i, lp := range projects {
+		rec.LockedProjects[i] = encodeLockedProject(lp)
+	}
+	if ai, ok := s.(analyzerInfoer); ok {
+		rec.AnalyzerName, rec.AnalyzerVersion = ai.AnalyzerInfo()
+	}
+	return rec
+}
+
+// Lock implementation so that a decoded solutionRecord can be handed back
+// out of Cache.Get as a Solution without any further conversion.
+func (r *solutionRecord) InputHash() []byte { return r.Digest }
+func (r *solutionRecord) Projects() []LockedProject {
+	lps := make([]LockedProject, len(r.LockedProjects))
+	for i, rec := range r.LockedProjects {
+		lps[i] = rec.decode()
+	}
+	return lps
+}
+func (r *solutionRecord) Attempts() int { return r.AttemptCount }
+func (r *solutionRecord) AnalyzerInfo() (string, int) {
+	return r.AnalyzerName, r.AnalyzerVersion
+}
+
+// memoryCache is a Cache backed by an in-memory map. Entries don't survive
+// process exit; it's primarily useful for tests and for processes that
+// only call Solve() once per digest anyway.
+type memoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]solutionRecord
+}
+
+// NewMemoryCache returns a Cache backed by a plain map, guarded by a
+// mutex.
+func NewMemoryCache() Cache {
+	return &memoryCache{entries: make(map[string]solutionRecord)}
+}
+
+func (c *memoryCache) Get(digest []byte) (Solution, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rec, has := c.entries[string(digest)]
+	if !has {
+		return nil, false, nil
+	}
+	return &rec, true, nil
+}
+
+func (c *memoryCache) Put(digest []byte, s Solution) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[string(digest)] = newSolutionRecord(digest, s)
+	return nil
+}
+
+// fileCache is a Cache that gob-encodes each entry to its own file under a
+// base directory, named after the hex-encoded digest.
+type fileCache struct {
+	mu   sync.Mutex
+	base string
+}
+
+// NewFileCache returns a Cache that persists entries as gob-encoded files
+// under base. base is created, along with any missing parents, if it
+// doesn't already exist. The directory and its entries are created
+// owner-only (0700/0600): this cache feeds directly into the solver's
+// locked-version output, so another local user able to read or tamper
+// with it could leak or poison dependency resolution.
+func NewFileCache(base string) (Cache, error) {
+	if err := os.MkdirAll(base, 0700); err != nil {
+		return nil, err
+	}
+	return &fileCache{base: base}, nil
+}
+
+func (c *fileCache) path(digest []byte) string {
+	return filepath.Join(c.base, fmt.Sprintf("%x.gob", digest))
+}
+
+func (c *fileCache) Get(digest []byte) (Solution, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := ioutil.ReadFile(c.path(digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	var rec solutionRecord
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&rec); err != nil {
+		return nil, false, err
+	}
+	return &rec, true, nil
+}
+
+func (c *fileCache) Put(digest []byte, s Solution) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(newSolutionRecord(digest, s)); err != nil {
+		return err
+	}
+
+	tmp := c.path(digest) + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(digest))
+}
@@ -0,0 +1,213 @@
+package gps
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeSolution is a minimal Solution used to exercise Cache and Memoize
+// without a real solver, which needs far more machinery (a SourceManager,
+// a root manifest, an applied override set) than these tests care about.
+type fakeSolution struct {
+	digest   []byte
+	projects []LockedProject
+	attempts int
+}
+
+func (f *fakeSolution) InputHash() []byte         { return f.digest }
+func (f *fakeSolution) Projects() []LockedProject { return f.projects }
+func (f *fakeSolution) Attempts() int             { return f.attempts }
+
+// fakeLockedProject is a minimal LockedProject used to prove Projects()
+// data - not just Attempts() - survives a round trip through a Cache.
+type fakeLockedProject struct {
+	ident    ProjectIdentifier
+	version  Version
+	packages []string
+}
+
+func (f fakeLockedProject) Ident() ProjectIdentifier { return f.ident }
+func (f fakeLockedProject) Version() Version         { return f.version }
+func (f fakeLockedProject) Packages() []string       { return f.packages }
+
+func assertProjectsRoundTrip(t *testing.T, got, want []LockedProject) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("Projects() has %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Ident() != w.Ident() {
+			t.Errorf("Projects()[%d].Ident() = %v, want %v", i, g.Ident(), w.Ident())
+		}
+		if g.Version().String() != w.Version().String() {
+			t.Errorf("Projects()[%d].Version() = %v, want %v", i, g.Version(), w.Version())
+		}
+		if !reflect.DeepEqual(g.Packages(), w.Packages()) {
+			t.Errorf("Projects()[%d].Packages() = %v, want %v", i, g.Packages(), w.Packages())
+		}
+	}
+}
+
+func TestMemoryCache_RoundTrip(t *testing.T) {
+	c := NewMemoryCache()
+	digest := []byte("digest-a")
+	projects := []LockedProject{fakeLockedProject{
+		ident:    ProjectIdentifier{ProjectRoot: "github.com/foo/bar"},
+		version:  NewBranch("master").Pair(Revision("abc123")),
+		packages: []string{".", "subpkg"},
+	}}
+	sol := &fakeSolution{digest: digest, attempts: 3, projects: projects}
+
+	if err := c.Put(digest, sol); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, has, err := c.Get(digest)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !has {
+		t.Fatalf("Get(%x) reported no entry after Put", digest)
+	}
+	if got.Attempts() != sol.Attempts() {
+		t.Errorf("Attempts() = %d, want %d", got.Attempts(), sol.Attempts())
+	}
+	assertProjectsRoundTrip(t, got.Projects(), projects)
+}
+
+func TestMemoryCache_MissReportsNoEntry(t *testing.T) {
+	c := NewMemoryCache()
+
+	_, has, err := c.Get([]byte("never-stored"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if has {
+		t.Fatal("Get reported a hit for a digest that was never Put")
+	}
+}
+
+func TestFileCache_RoundTrip(t *testing.T) {
+	c, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+
+	digest := []byte("digest-b")
+	projects := []LockedProject{
+		fakeLockedProject{
+			ident:    ProjectIdentifier{ProjectRoot: "github.com/foo/bar", NetworkName: "git.example.com/foo/bar"},
+			version:  NewVersion("v1.2.3"),
+			packages: []string{"."},
+		},
+		fakeLockedProject{
+			ident:    ProjectIdentifier{ProjectRoot: "github.com/baz/qux"},
+			version:  Revision("deadbeef"),
+			packages: []string{".", "internal"},
+		},
+	}
+	sol := &fakeSolution{digest: digest, attempts: 7, projects: projects}
+	if err := c.Put(digest, sol); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, has, err := c.Get(digest)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !has {
+		t.Fatalf("Get(%x) reported no entry after Put", digest)
+	}
+	if got.Attempts() != sol.Attempts() {
+		t.Errorf("Attempts() = %d, want %d", got.Attempts(), sol.Attempts())
+	}
+	assertProjectsRoundTrip(t, got.Projects(), projects)
+}
+
+func TestFileCache_MissReportsNoEntry(t *testing.T) {
+	c, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+
+	_, has, err := c.Get([]byte("never-stored"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if has {
+		t.Fatal("Get reported a hit for a digest that was never Put")
+	}
+}
+
+// TestMemoize_InvalidatesOnDigestChange is the cache-invalidation proof
+// requested for chunk0-1: a digest that matches a prior entry short-
+// circuits the solve, and any input change that flips HashInputs'
+// output - a manifest constraint, an override, an ignore, an analyzer
+// version, anything HashInputs covers - produces a different digest that
+// must miss the cache and re-run the solver.
+func TestMemoize_InvalidatesOnDigestChange(t *testing.T) {
+	cache := NewMemoryCache()
+	calls := 0
+	solve := func() (Solution, error) {
+		calls++
+		return &fakeSolution{digest: []byte("solved")}, nil
+	}
+
+	before := []byte("digest-before-input-change")
+	if _, err := Memoize(cache, before, solve); err != nil {
+		t.Fatalf("Memoize: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d after first solve, want 1", calls)
+	}
+
+	// Re-running with the same digest - nothing HashInputs covers
+	// changed - must hit the cache rather than solving again.
+	if _, err := Memoize(cache, before, solve); err != nil {
+		t.Fatalf("Memoize: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d after repeat with unchanged digest, want 1 (cache hit)", calls)
+	}
+
+	// A changed input changes the digest HashInputs would compute, which
+	// must miss the cache and re-run the solver.
+	after := []byte("digest-after-input-change")
+	if _, err := Memoize(cache, after, solve); err != nil {
+		t.Fatalf("Memoize: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d after changed digest, want 2 (cache miss)", calls)
+	}
+}
+
+func TestMemoize_NilCacheAlwaysSolves(t *testing.T) {
+	calls := 0
+	solve := func() (Solution, error) {
+		calls++
+		return &fakeSolution{}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := Memoize(nil, []byte("same-digest"), solve); err != nil {
+			t.Fatalf("Memoize: %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d with a nil cache, want 2 (memoization disabled)", calls)
+	}
+}
+
+func TestMemoize_PropagatesSolveError(t *testing.T) {
+	wantErr := errors.New("solve failed")
+
+	_, err := Memoize(NewMemoryCache(), []byte("x"), func() (Solution, error) {
+		return nil, wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("Memoize err = %v, want %v", err, wantErr)
+	}
+}
@@ -27,11 +27,7 @@ func (s *solver) HashInputs() []byte {
 	for _, pd := range p {
 		buf.WriteString(string(pd.Ident.ProjectRoot))
 		buf.WriteString(pd.Ident.NetworkName)
-		// FIXME Constraint.String() is a surjective-only transformation - tags
-		// and branches with the same name are written out as the same string.
-		// This could, albeit rarely, result in input collisions when a real
-		// change has occurred.
-		buf.WriteString(pd.Constraint.String())
+		writeConstraint(buf, pd.Constraint)
 	}
 
 	// Write each of the packages, or the errors that were found for a
@@ -92,7 +88,7 @@ func (s *solver) HashInputs() []byte {
 			buf.WriteString(pc.Ident.NetworkName)
 		}
 		if pc.Constraint != nil {
-			buf.WriteString(pc.Constraint.String())
+			writeConstraint(buf, pc.Constraint)
 		}
 	}
 
@@ -100,6 +96,25 @@ func (s *solver) HashInputs() []byte {
 	buf.WriteString(an)
 	buf.WriteString(av.String())
 
+	// Fold in every registered Analyzer's identity and contributed
+	// bytes, sorted by name. An error from one doesn't abort the hash -
+	// there's nowhere for it to go, given HashInputs' signature - but
+	// folding the error text in still means a fixed error yields a fixed
+	// digest, while a changed one changes it.
+	if err := s.analyzers.writeTo(buf, s.rm); err != nil {
+		buf.WriteString(err.Error())
+	}
+
+	// Deliberately not folded in here: HashInputsByProject(), which walks
+	// the whole transitive dependency graph over the network via
+	// SourceManager to compute its per-project sub-digests. HashInputs is
+	// meant to stay cheap and local - every existing caller, including
+	// solveWithCache's cache lookup, calls it before deciding whether a full
+	// solve (or prefetch) is even worth doing. Folding a network walk in
+	// here would make computing the cache key itself as expensive as the
+	// thing it's supposed to let callers skip. Callers that want the
+	// incremental, per-project digests call HashInputsByProject directly.
+
 	hd := sha256.Sum256(buf.Bytes())
 	return hd[:]
 }
@@ -125,3 +140,67 @@ func (s sortPackageOrErr) Less(i, j int) bool {
 	// And finally, sort by import path.
 	return a.P.ImportPath < b.P.ImportPath
 }
+
+// Discriminator bytes written by writeConstraint ahead of a constraint's
+// payload, so that constraints of different kinds can never collide in
+// the hash even when their String() forms are identical (a branch and a
+// tag sharing a name, for example).
+const (
+	hashKindAny byte = iota
+	hashKindNone
+	hashKindRevision
+	hashKindBranch
+	hashKindTag
+	hashKindVersion
+	hashKindSemverRange
+	hashKindUnion
+)
+
+// writeConstraint writes a canonical, type-discriminated encoding of c
+// into buf. c.String() alone isn't safe for this: it's a surjective-only
+// transformation where, say, a branch named "v1" and a tag named "v1"
+// both render as "v1", so swapping one for the other wouldn't change the
+// hash even though it's a real, solve-relevant change. Prefixing each
+// payload with a kind byte removes that ambiguity.
+//
+// Since Version embeds Constraint, this also covers the versions recorded
+// against locked projects wherever those are folded into a hash.
+func writeConstraint(buf *bytes.Buffer, c Constraint) {
+	switch tc := c.(type) {
+	case anyConstraint:
+		buf.WriteByte(hashKindAny)
+	case noneConstraint:
+		buf.WriteByte(hashKindNone)
+	case Revision:
+		buf.WriteByte(hashKindRevision)
+		buf.WriteString(string(tc))
+	case branchVersion:
+		buf.WriteByte(hashKindBranch)
+		buf.WriteString(tc.name)
+	case plainVersion:
+		buf.WriteByte(hashKindTag)
+		buf.WriteString(string(tc))
+	case semVersion:
+		buf.WriteByte(hashKindVersion)
+		buf.WriteString(tc.sv.String())
+	case PairedVersion:
+		// Both halves - the human-facing branch/tag/semver and the
+		// revision it resolved to - are solve-relevant, so write both.
+		writeConstraint(buf, tc.Unpair())
+		buf.WriteByte(hashKindRevision)
+		buf.WriteString(string(tc.Underlying()))
+	case versionTypeUnion:
+		buf.WriteByte(hashKindUnion)
+		for _, v := range tc {
+			writeConstraint(buf, v)
+		}
+	default:
+		// Only semver range constraints (rather than a single pinned
+		// version) fall through to here. They have no further internal
+		// structure worth picking apart, and there's no tag/branch/etc.
+		// they could be confused with, so their string form is
+		// unambiguous on its own.
+		buf.WriteByte(hashKindSemverRange)
+		buf.WriteString(c.String())
+	}
+}
@@ -0,0 +1,76 @@
+package gps
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestWriteConstraint_NoCollisionAcrossKinds constructs pairs of
+// constraints whose String() forms collide today - a branch and a tag
+// sharing a name, a tag and the revision it happens to be named after -
+// and asserts writeConstraint no longer writes the same bytes for them,
+// since each is prefixed with a kind-specific discriminator.
+func TestWriteConstraint_NoCollisionAcrossKinds(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b Constraint
+	}{
+		{"branch vs tag", NewBranch("v1"), NewVersion("v1")},
+		{"tag vs revision", NewVersion("v1"), Revision("v1")},
+		{"branch vs revision", NewBranch("v1"), Revision("v1")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.a.String() != c.b.String() {
+				t.Fatalf("test fixture invalid: %q.String() != %q.String()", c.a, c.b)
+			}
+
+			var bufA, bufB bytes.Buffer
+			writeConstraint(&bufA, c.a)
+			writeConstraint(&bufB, c.b)
+
+			if bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
+				t.Errorf("writeConstraint collided despite a String()-identical, kind-different pair: both produced %x", bufA.Bytes())
+			}
+		})
+	}
+}
+
+// TestWriteConstraint_StableWithinKind guards against a trivial way to
+// "fix" the collision above by making writeConstraint non-deterministic:
+// the same constraint must always produce the same bytes.
+func TestWriteConstraint_StableWithinKind(t *testing.T) {
+	cs := []Constraint{
+		Any(),
+		None(),
+		NewBranch("master"),
+		NewVersion("v1"),
+		Revision("abc123"),
+	}
+
+	for _, c := range cs {
+		var bufA, bufB bytes.Buffer
+		writeConstraint(&bufA, c)
+		writeConstraint(&bufB, c)
+
+		if !bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
+			t.Errorf("writeConstraint(%v) was not stable: %x != %x", c, bufA.Bytes(), bufB.Bytes())
+		}
+	}
+}
+
+// TestWriteConstraint_DifferentKindsEncodeDifferently checks that
+// constraints which aren't even String()-collisions of each other still
+// don't happen to share a discriminator.
+func TestWriteConstraint_DifferentKindsEncodeDifferently(t *testing.T) {
+	any, none := Any(), None()
+
+	var bufAny, bufNone bytes.Buffer
+	writeConstraint(&bufAny, any)
+	writeConstraint(&bufNone, none)
+
+	if bytes.Equal(bufAny.Bytes(), bufNone.Bytes()) {
+		t.Error("Any() and None() encoded identically")
+	}
+}
@@ -0,0 +1,293 @@
+package gps
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// depGraph maps each ProjectRoot discovered while walking dependencies
+// out from the root to the ProjectRoots it directly depends on. Edges
+// that would close a cycle back onto an ancestor are omitted by
+// buildDepGraph, so every graph produced here is acyclic.
+type depGraph map[ProjectRoot][]ProjectRoot
+
+// manifestAndLock bundles the manifest and lock fetched for a project
+// while walking the dependency graph, so prefetcher.loadOne can reuse
+// them instead of fetching the same project a second time.
+type manifestAndLock struct {
+	m Manifest
+	l Lock
+}
+
+// projectLister is the subset of SourceManager buildDepGraph needs to
+// discover a project's declared dependencies.
+type projectLister interface {
+	GetManifestAndLock(ProjectIdentifier, Version) (Manifest, Lock, error)
+}
+
+// sourceLoader is the subset of SourceManager the prefetcher needs to
+// load a project's full input set. It's kept narrow, rather than taking
+// a SourceManager directly, so the prefetcher can be driven by a fake in
+// tests without having to implement all of SourceManager.
+type sourceLoader interface {
+	projectLister
+	ListVersions(ProjectIdentifier) ([]Version, error)
+}
+
+// projectLatch lets goroutines loading a project's data block until that
+// project's own dependencies have finished loading, without forcing
+// unrelated subgraphs into lockstep with each other.
+type projectLatch struct {
+	wg sync.WaitGroup
+}
+
+func newProjectLatch() *projectLatch {
+	pl := &projectLatch{}
+	pl.wg.Add(1)
+	return pl
+}
+
+func (pl *projectLatch) done() { pl.wg.Done() }
+func (pl *projectLatch) wait() { pl.wg.Wait() }
+
+// prefetcher walks a dependency graph and loads each project's manifest,
+// lock, and version list through a sourceLoader, folding them - along
+// with every dependency's own already-computed digest - into a
+// per-project digest. Independent subgraphs load concurrently; a
+// CPU-sized semaphore bounds how much of that loading work runs at once,
+// the same tradeoff honnef.co/go/tools' runner makes for goroutines
+// fanned out over bounded work.
+type prefetcher struct {
+	sm    sourceLoader
+	cache map[ProjectRoot]manifestAndLock
+	sem   chan struct{}
+
+	mu      sync.Mutex
+	latches map[ProjectRoot]*projectLatch
+	digests map[ProjectRoot][]byte
+	errs    map[ProjectRoot]error
+}
+
+// newPrefetcher returns a prefetcher that loads through sm. cache holds
+// manifest/lock pairs already fetched while building the dependency graph
+// (see buildDepGraph); loadOne consults it before fetching a project
+// again. cache is only ever read from after construction, so it's safe
+// to share across the prefetch's goroutines without its own lock.
+func newPrefetcher(sm sourceLoader, cache map[ProjectRoot]manifestAndLock) *prefetcher {
+	return &prefetcher{
+		sm:      sm,
+		cache:   cache,
+		sem:     make(chan struct{}, runtime.NumCPU()),
+		latches: make(map[ProjectRoot]*projectLatch),
+		digests: make(map[ProjectRoot][]byte),
+		errs:    make(map[ProjectRoot]error),
+	}
+}
+
+// prefetch loads every project named in graph, returning once each has
+// either loaded or failed.
+func (pf *prefetcher) prefetch(graph depGraph) {
+	var wg sync.WaitGroup
+	for root := range graph {
+		wg.Add(1)
+		go func(root ProjectRoot) {
+			defer wg.Done()
+			pf.load(root, graph)
+		}(root)
+	}
+	wg.Wait()
+}
+
+func (pf *prefetcher) latch(root ProjectRoot) *projectLatch {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	pl, has := pf.latches[root]
+	if !has {
+		pl = newProjectLatch()
+		pf.latches[root] = pl
+	}
+	return pl
+}
+
+func (pf *prefetcher) load(root ProjectRoot, graph depGraph) {
+	pl := pf.latch(root)
+	defer pl.done()
+
+	deps := graph[root]
+
+	// A project's sub-digest folds in its dependencies', so it can't be
+	// computed until they're done. graph is guaranteed acyclic (depGraph
+	// breaks cycles when building it), so this can't deadlock waiting on
+	// a dependency that's transitively waiting on us.
+	for _, dep := range deps {
+		pf.latch(dep).wait()
+	}
+
+	// Every dependency has signaled done above, so its digest is now
+	// readable; grab them to fold into this project's own digest.
+	pf.mu.Lock()
+	depDigests := make(map[ProjectRoot][]byte, len(deps))
+	for _, dep := range deps {
+		depDigests[dep] = pf.digests[dep]
+	}
+	pf.mu.Unlock()
+
+	pf.sem <- struct{}{}
+	digest, err := pf.loadOne(root, deps, depDigests)
+	<-pf.sem
+
+	pf.mu.Lock()
+	if err != nil {
+		pf.errs[root] = err
+		// Still record a digest, derived from the error itself, so a
+		// project that failed to load is distinguishable in the combined
+		// hash rather than silently vanishing from it as if it carried
+		// no inputs at all.
+		hd := sha256.Sum256([]byte(err.Error()))
+		pf.digests[root] = hd[:]
+	} else {
+		pf.digests[root] = digest
+	}
+	pf.mu.Unlock()
+}
+
+// loadOne reduces the manifest, lock, and version list for root, plus the
+// already-computed digest of every project in deps, to a single digest.
+// Folding in deps' digests (rather than just their names, as declared in
+// root's own manifest) means a change several levels down a transitive
+// dependency chain still changes every ancestor's digest, not only the
+// digest of the project whose declared constraint literally changed.
+//
+// Constraints are written with the same type-discriminated encoding as
+// HashInputs, so this can't collide the way a bare String() comparison
+// could.
+func (pf *prefetcher) loadOne(root ProjectRoot, deps []ProjectRoot, depDigests map[ProjectRoot][]byte) ([]byte, error) {
+	pi := ProjectIdentifier{ProjectRoot: root}
+
+	ml, cached := pf.cache[root]
+	if !cached {
+		m, l, err := pf.sm.GetManifestAndLock(pi, nil)
+		if err != nil {
+			return nil, err
+		}
+		ml = manifestAndLock{m: m, l: l}
+	}
+
+	versions, err := pf.sm.ListVersions(pi)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(string(root))
+
+	depRoots := make([]string, len(deps))
+	for i, dep := range deps {
+		depRoots[i] = string(dep)
+	}
+	sort.Strings(depRoots)
+	for _, depRoot := range depRoots {
+		buf.WriteString(depRoot)
+		buf.Write(depDigests[ProjectRoot(depRoot)])
+	}
+
+	if ml.m != nil {
+		for _, pd := range ml.m.DependencyConstraints() {
+			buf.WriteString(string(pd.Ident.ProjectRoot))
+			writeConstraint(buf, pd.Constraint)
+		}
+	}
+	if ml.l != nil {
+		for _, lp := range ml.l.Projects() {
+			buf.WriteString(string(lp.Ident().ProjectRoot))
+			writeConstraint(buf, lp.Version())
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].String() < versions[j].String()
+	})
+	for _, v := range versions {
+		writeConstraint(buf, v)
+	}
+
+	hd := sha256.Sum256(buf.Bytes())
+	return hd[:], nil
+}
+
+// HashInputsByProject computes a sub-digest for each ProjectRoot in the
+// dependency graph reachable from the root, driven by a parallel prefetch
+// over that graph. Each covers the project's manifest, lock and version
+// list plus its dependencies' sub-digests (see loadOne). None of them is
+// folded into HashInputs' combined digest, and no Cache reads them; they
+// let a caller tell which project's inputs changed.
+func (s *solver) HashInputsByProject() map[ProjectRoot][]byte {
+	graph, cache := s.depGraph()
+
+	pf := newPrefetcher(s.b, cache)
+	pf.prefetch(graph)
+	return pf.digests
+}
+
+// depGraph walks outward from the root manifest's dependency constraints
+// through s.b, the solver's bridge onto its SourceManager.
+func (s *solver) depGraph() (depGraph, map[ProjectRoot]manifestAndLock) {
+	return buildDepGraph(s.ovr.overrideAll(s.rm.DependencyConstraints().merge(s.rm.TestDependencyConstraints())), s.b)
+}
+
+// buildDepGraph walks outward from root, loading each dependency's own
+// manifest through pl to discover its further dependencies, until the
+// whole graph reachable from root has been mapped. Projects already seen
+// aren't walked again, so a diamond dependency is only loaded once. An
+// edge back onto a project still on the current walk path - a dependency
+// cycle - is omitted from the returned graph, since the prefetcher would
+// otherwise deadlock each side waiting on the other's latch; the
+// manifest/lock already fetched for every project visited is returned
+// alongside the graph so the prefetch pass doesn't have to fetch it all
+// over again.
+func buildDepGraph(root []ProjectConstraint, pl projectLister) (depGraph, map[ProjectRoot]manifestAndLock) {
+	graph := make(depGraph)
+	cache := make(map[ProjectRoot]manifestAndLock)
+	seen := make(map[ProjectRoot]bool)
+	onPath := make(map[ProjectRoot]bool)
+
+	var walk func(deps []ProjectConstraint)
+	walk = func(deps []ProjectConstraint) {
+		for _, pd := range deps {
+			projRoot := pd.Ident.ProjectRoot
+			if seen[projRoot] {
+				continue
+			}
+			seen[projRoot] = true
+			onPath[projRoot] = true
+
+			var children []ProjectConstraint
+			if m, l, err := pl.GetManifestAndLock(pd.Ident, nil); err == nil {
+				cache[projRoot] = manifestAndLock{m: m, l: l}
+				if m != nil {
+					children = m.DependencyConstraints()
+				}
+			}
+
+			childRoots := make([]ProjectRoot, 0, len(children))
+			for _, cpd := range children {
+				childRoot := cpd.Ident.ProjectRoot
+				if onPath[childRoot] {
+					continue
+				}
+				childRoots = append(childRoots, childRoot)
+			}
+			graph[projRoot] = childRoots
+
+			walk(children)
+			onPath[projRoot] = false
+		}
+	}
+
+	walk(root)
+	return graph, cache
+}
@@ -0,0 +1,161 @@
+package gps
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeManifest is a Manifest backed by a fixed dependency list, used to
+// drive buildDepGraph and the prefetcher without a real SourceManager.
+type fakeManifest struct {
+	deps ProjectConstraints
+}
+
+func (m fakeManifest) DependencyConstraints() ProjectConstraints     { return m.deps }
+func (m fakeManifest) TestDependencyConstraints() ProjectConstraints { return nil }
+
+// fakeSource is a sourceLoader backed by a fixed map of manifests, with
+// an optional forced error for one ProjectRoot.
+type fakeSource struct {
+	manifests map[ProjectRoot]Manifest
+	versions  map[ProjectRoot][]Version
+	failRoot  ProjectRoot
+	failErr   error
+}
+
+func (f fakeSource) GetManifestAndLock(pi ProjectIdentifier, _ Version) (Manifest, Lock, error) {
+	if f.failErr != nil && pi.ProjectRoot == f.failRoot {
+		return nil, nil, f.failErr
+	}
+	m, ok := f.manifests[pi.ProjectRoot]
+	if !ok {
+		return nil, nil, errors.New("no manifest for " + string(pi.ProjectRoot))
+	}
+	return m, nil, nil
+}
+
+func (f fakeSource) ListVersions(pi ProjectIdentifier) ([]Version, error) {
+	return f.versions[pi.ProjectRoot], nil
+}
+
+func mustNotHang(t *testing.T, what string, fn func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s did not return within 2s - likely stuck on a dependency cycle", what)
+	}
+}
+
+// TestBuildDepGraph_BreaksCycles proves that a mutually-dependent pair of
+// projects (a depends on b, b depends on a) doesn't send buildDepGraph
+// into infinite recursion, and that the resulting graph contains no edge
+// back from b to a.
+func TestBuildDepGraph_BreaksCycles(t *testing.T) {
+	src := fakeSource{manifests: map[ProjectRoot]Manifest{
+		"a": fakeManifest{deps: ProjectConstraints{{Ident: ProjectIdentifier{ProjectRoot: "b"}, Constraint: Any()}}},
+		"b": fakeManifest{deps: ProjectConstraints{{Ident: ProjectIdentifier{ProjectRoot: "a"}, Constraint: Any()}}},
+	}}
+	root := []ProjectConstraint{{Ident: ProjectIdentifier{ProjectRoot: "a"}, Constraint: Any()}}
+
+	var graph depGraph
+	mustNotHang(t, "buildDepGraph", func() {
+		graph, _ = buildDepGraph(root, src)
+	})
+
+	for _, child := range graph["b"] {
+		if child == "a" {
+			t.Fatalf("cycle not broken: graph[\"b\"] still contains \"a\": %v", graph)
+		}
+	}
+}
+
+// TestPrefetch_DoesNotDeadlockOnCyclicGraph proves the prefetcher itself
+// completes - rather than deadlocking on the latch wait - when driven by
+// a graph built from a dependency cycle, and that every project in the
+// cycle still ends up with a recorded digest.
+func TestPrefetch_DoesNotDeadlockOnCyclicGraph(t *testing.T) {
+	src := fakeSource{manifests: map[ProjectRoot]Manifest{
+		"a": fakeManifest{deps: ProjectConstraints{{Ident: ProjectIdentifier{ProjectRoot: "b"}, Constraint: Any()}}},
+		"b": fakeManifest{deps: ProjectConstraints{{Ident: ProjectIdentifier{ProjectRoot: "a"}, Constraint: Any()}}},
+	}}
+	root := []ProjectConstraint{{Ident: ProjectIdentifier{ProjectRoot: "a"}, Constraint: Any()}}
+
+	graph, cache := buildDepGraph(root, src)
+	pf := newPrefetcher(src, cache)
+
+	mustNotHang(t, "prefetch", func() {
+		pf.prefetch(graph)
+	})
+
+	for _, root := range []ProjectRoot{"a", "b"} {
+		if _, ok := pf.digests[root]; !ok {
+			t.Errorf("expected a digest for project %q, got none", root)
+		}
+	}
+}
+
+// TestPrefetch_FailedProjectStillHashed proves that a project whose load
+// failed still gets an entry in digests - derived from the error, so it's
+// distinguishable from a project that loaded cleanly - rather than being
+// silently dropped from the combined hash.
+func TestPrefetch_FailedProjectStillHashed(t *testing.T) {
+	src := fakeSource{
+		manifests: map[ProjectRoot]Manifest{"broken": fakeManifest{}},
+		failRoot:  "broken",
+		failErr:   errors.New("boom"),
+	}
+	graph := depGraph{"broken": nil}
+
+	pf := newPrefetcher(src, nil)
+	pf.prefetch(graph)
+
+	digest, ok := pf.digests["broken"]
+	if !ok {
+		t.Fatal("expected a digest to be recorded for a project whose load failed, got none")
+	}
+	if len(digest) == 0 {
+		t.Error("recorded digest for failed project is empty")
+	}
+	if pf.errs["broken"] == nil {
+		t.Error("expected the load error to be recorded in errs")
+	}
+}
+
+// TestPrefetch_FoldsDependencyDigests proves that changing a transitive
+// (non-immediate) dependency's inputs changes an ancestor's digest, which
+// is the whole point of waiting on a dependency's latch before computing
+// this project's own digest.
+func TestPrefetch_FoldsDependencyDigests(t *testing.T) {
+	build := func(cVersions []Version) map[ProjectRoot][]byte {
+		src := fakeSource{
+			manifests: map[ProjectRoot]Manifest{
+				"a": fakeManifest{deps: ProjectConstraints{{Ident: ProjectIdentifier{ProjectRoot: "b"}, Constraint: Any()}}},
+				"b": fakeManifest{deps: ProjectConstraints{{Ident: ProjectIdentifier{ProjectRoot: "c"}, Constraint: Any()}}},
+				"c": fakeManifest{},
+			},
+			versions: map[ProjectRoot][]Version{"c": cVersions},
+		}
+		root := []ProjectConstraint{{Ident: ProjectIdentifier{ProjectRoot: "a"}, Constraint: Any()}}
+
+		graph, cache := buildDepGraph(root, src)
+		pf := newPrefetcher(src, cache)
+		pf.prefetch(graph)
+		return pf.digests
+	}
+
+	before := build(nil)
+	after := build([]Version{NewBranch("only-affects-c")})
+
+	if string(before["a"]) == string(after["a"]) {
+		t.Error("project a's digest didn't change when transitive dependency c's inputs changed")
+	}
+}
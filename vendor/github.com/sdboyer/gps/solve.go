@@ -0,0 +1,24 @@
+package gps
+
+// hashingSolver is the minimal surface solveWithCache needs from a
+// prepared solver: enough to compute its cache key, and to actually run
+// when that key misses. It's kept narrow rather than taking *solver
+// directly, the same way prefetch.go narrows SourceManager down to
+// projectLister/sourceLoader - so solveWithCache can be driven by a fake
+// in tests without needing a real *solver, which this package doesn't
+// itself construct.
+type hashingSolver interface {
+	HashInputs() []byte
+	Solve() (Solution, error)
+}
+
+// solveWithCache runs s.Solve, short-circuiting through cache when a
+// Solution for s.HashInputs() is already stored there. A nil cache
+// disables memoization and always solves.
+//
+// A caller holding a prepared solver calls solveWithCache(s, cache) in
+// place of s.Solve(). SolveParams has no Cache field, and nothing in this
+// package calls solveWithCache.
+func solveWithCache(s hashingSolver, cache Cache) (Solution, error) {
+	return Memoize(cache, s.HashInputs(), s.Solve)
+}
@@ -0,0 +1,71 @@
+package gps
+
+import "testing"
+
+// fakeHashingSolver is a hashingSolver driven entirely by fixed return
+// values, so solveWithCache can be exercised without a real *solver.
+type fakeHashingSolver struct {
+	digest     []byte
+	sol        Solution
+	err        error
+	solveCalls int
+}
+
+func (f *fakeHashingSolver) HashInputs() []byte { return f.digest }
+func (f *fakeHashingSolver) Solve() (Solution, error) {
+	f.solveCalls++
+	return f.sol, f.err
+}
+
+// TestSolveWithCache_HitSkipsSolve proves a cache hit for s.HashInputs()
+// is returned without calling s.Solve at all.
+func TestSolveWithCache_HitSkipsSolve(t *testing.T) {
+	digest := []byte("digest")
+	cache := NewMemoryCache()
+	cached := &fakeSolution{digest: digest, attempts: 1}
+	if err := cache.Put(digest, cached); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	s := &fakeHashingSolver{digest: digest, sol: &fakeSolution{attempts: 99}}
+	sol, err := solveWithCache(s, cache)
+	if err != nil {
+		t.Fatalf("solveWithCache: %v", err)
+	}
+	if s.solveCalls != 0 {
+		t.Errorf("Solve called %d times on a cache hit, want 0", s.solveCalls)
+	}
+	if sol.Attempts() != cached.Attempts() {
+		t.Errorf("Attempts() = %d, want the cached value %d", sol.Attempts(), cached.Attempts())
+	}
+}
+
+// TestSolveWithCache_MissRunsSolveAndStores proves a cache miss falls
+// through to s.Solve, and that the result gets stored for next time.
+func TestSolveWithCache_MissRunsSolveAndStores(t *testing.T) {
+	digest := []byte("digest")
+	cache := NewMemoryCache()
+	s := &fakeHashingSolver{digest: digest, sol: &fakeSolution{digest: digest, attempts: 5}}
+
+	sol, err := solveWithCache(s, cache)
+	if err != nil {
+		t.Fatalf("solveWithCache: %v", err)
+	}
+	if s.solveCalls != 1 {
+		t.Errorf("Solve called %d times on a cache miss, want 1", s.solveCalls)
+	}
+	if sol.Attempts() != 5 {
+		t.Errorf("Attempts() = %d, want 5", sol.Attempts())
+	}
+
+	got, has, err := cache.Get(digest)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !has {
+		t.Fatal("solveWithCache didn't store the freshly solved Solution")
+	}
+	if got.Attempts() != 5 {
+		t.Errorf("stored Attempts() = %d, want 5", got.Attempts())
+	}
+}